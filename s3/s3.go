@@ -1,94 +1,322 @@
 package s3
 
 import (
+	"context"
 	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"hash"
+	"hash/crc32"
 	"io"
+	"math"
+	"math/rand"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	client "github.com/aws/aws-sdk-go/service/s3"
-	manager "github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	client "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithy "github.com/aws/smithy-go"
 )
 
 const megabyte = 1024 * 1024
 
+// objectExistsWaitTimeout bounds how long Upload waits for a freshly put
+// object to become visible to HeadObject before giving up.
+const objectExistsWaitTimeout = 5 * time.Minute
+
+// normalizeEndpoint turns a Config.Endpoint value into a full URL, adding an
+// https:// (or http:// when disableSSL is set) scheme if the caller didn't
+// supply one of their own.
+func normalizeEndpoint(endpoint string, disableSSL bool) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	scheme := "https"
+	if disableSSL {
+		scheme = "http"
+	}
+	return scheme + "://" + endpoint
+}
+
+// PutObjectAPIClient is the subset of *s3.Client that manager.Uploader needs
+// to perform an upload, including multipart uploads. It is satisfied by
+// *s3.Client, so callers can substitute a mock that implements only these
+// methods instead of the whole client.
+type PutObjectAPIClient interface {
+	PutObject(ctx context.Context, params *client.PutObjectInput, optFns ...func(*client.Options)) (*client.PutObjectOutput, error)
+	UploadPart(ctx context.Context, params *client.UploadPartInput, optFns ...func(*client.Options)) (*client.UploadPartOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *client.CreateMultipartUploadInput, optFns ...func(*client.Options)) (*client.CreateMultipartUploadOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *client.CompleteMultipartUploadInput, optFns ...func(*client.Options)) (*client.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *client.AbortMultipartUploadInput, optFns ...func(*client.Options)) (*client.AbortMultipartUploadOutput, error)
+}
+
+// HeadObjectAPIClient is the subset of *s3.Client that HeadGetter needs to
+// check whether an object exists and to read back its ETag.
+type HeadObjectAPIClient interface {
+	HeadObject(ctx context.Context, params *client.HeadObjectInput, optFns ...func(*client.Options)) (*client.HeadObjectOutput, error)
+}
+
+// GetObjectAPIClient is the subset of *s3.Client that Downloader needs to
+// fetch an object, whether via manager.Downloader or a plain sequential GET.
+type GetObjectAPIClient interface {
+	GetObject(ctx context.Context, params *client.GetObjectInput, optFns ...func(*client.Options)) (*client.GetObjectOutput, error)
+}
+
+// Backoff controls the delay the retry loops in Upload/Download wait between
+// attempts.
+type Backoff interface {
+	// Wait blocks for the delay appropriate to the given retry attempt
+	// (0-based), returning early with ctx.Err() if ctx is cancelled or times
+	// out first.
+	Wait(ctx context.Context, attempt int) error
+}
+
+// ExponentialBackoff is the default Backoff: delay grows as
+// Base*Multiplier^attempt, capped at Max, with full jitter (a random delay
+// between 0 and the capped value) applied per the AWS SDK standard
+// retryer's approach, so that many clients retrying the same failure don't
+// all hammer S3 again at the same instant.
+type ExponentialBackoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+const (
+	defaultBackoffBase       = 100 * time.Millisecond
+	defaultBackoffMax        = 20 * time.Second
+	defaultBackoffMultiplier = 2.0
+)
+
+func (b *ExponentialBackoff) Wait(ctx context.Context, attempt int) (err error) {
+	timer := time.NewTimer(b.delay(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *ExponentialBackoff) delay(attempt int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = defaultBackoffBase
+	}
+	maxDelay := b.Max
+	if maxDelay <= 0 {
+		maxDelay = defaultBackoffMax
+	}
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultBackoffMultiplier
+	}
+
+	capped := float64(base) * math.Pow(multiplier, float64(attempt))
+	if capped > float64(maxDelay) || math.IsInf(capped, 1) {
+		capped = float64(maxDelay)
+	}
+
+	return time.Duration(rand.Int63n(int64(capped) + 1)) //nolint:gosec
+}
+
+// isRetryable reports whether err is worth retrying. Context cancellation
+// and terminal S3 errors (the object/bucket doesn't exist, or the caller
+// isn't authorized) are not: retrying them would just fail the same way
+// again.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NoSuchBucket", "NoSuchKey", "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+			return false
+		}
+	}
+	return true
+}
+
 type UploaderInterface interface {
-	upload(s3 *S3, filePath, s3Path string) (out *manager.UploadOutput, err error)
+	upload(ctx context.Context, s3 *S3, r io.ReadSeeker, size int64, s3Path string, opts *UploadOptions) (out *manager.UploadOutput, err error)
 }
 
 type DownloaderInterface interface {
-	download(s3 *S3, filePath, s3Path string) (n int64, err error)
+	download(ctx context.Context, s3 *S3, w io.Writer, s3Path string) (n int64, err error)
 }
 
 type HeadGetterInterface interface {
-	get(s3 *S3, s3Path string) (out *client.HeadObjectOutput, err error)
+	get(ctx context.Context, s3 *S3, s3Path string) (out *client.HeadObjectOutput, err error)
 }
 
+// HashMakerInterface hashes an object's content the same way S3 does when
+// computing an ETag, so it can be compared against the ETag S3 reports back.
+// It takes an io.ReadSeeker rather than a file path so verification can run
+// against any seekable source: a temp file, a memory buffer, a tar entry.
 type HashMakerInterface interface {
-	makeMultiPartFromFile(filePath string, partSize int) (hash string, err error)
-	makeSinglePartFromFile(filePath string) (hash string, err error)
+	makeMultiPart(r io.ReadSeeker, partSize int) (hash string, err error)
+	makeSinglePart(r io.ReadSeeker) (hash string, err error)
+}
+
+// ChecksumVerifierInterface computes one of S3's additional checksums
+// (CRC32C, SHA256) over an object's content, the same way HashMakerInterface
+// does for the MD5 ETag, so it can be compared against the checksum S3
+// reports back via HeadObject's Checksum* fields.
+type ChecksumVerifierInterface interface {
+	makeMultiPart(r io.ReadSeeker, partSize int) (checksum string, err error)
+	makeSinglePart(r io.ReadSeeker) (checksum string, err error)
 }
 
 type S3 struct {
-	credentials  *credentials.Credentials
-	session      *session.Session
-	region       *string
-	bucket       *string
-	s3           *client.S3
-	retryCnt     int
-	waitDuration time.Duration
-	uploader     UploaderInterface
-	downloader   DownloaderInterface
-	headGetter   HeadGetterInterface
-	hashMaker    HashMakerInterface
+	bucket            *string
+	putClient         PutObjectAPIClient
+	headClient        HeadObjectAPIClient
+	getClient         GetObjectAPIClient
+	retryCnt          int
+	backoff           Backoff
+	uploader          UploaderInterface
+	downloader        DownloaderInterface
+	headGetter        HeadGetterInterface
+	hashMaker         HashMakerInterface
+	checksumAlgorithm string
+	checksumVerifier  ChecksumVerifierInterface
 }
 
 type Config struct {
-	ID           string
-	Secret       string
-	Token        string
-	Region       string
-	Bucket       string
-	RetryCnt     int
-	WaitDuration time.Duration
-	Uploader     UploaderInterface
-	Downloader   DownloaderInterface
-	HeadGetter   HeadGetterInterface
-	HashMaker    HashMakerInterface
+	ID       string
+	Secret   string
+	Token    string
+	Region   string
+	Bucket   string
+	RetryCnt int
+	// Backoff controls the delay between retry attempts. The default,
+	// *ExponentialBackoff, follows the same base/max/multiplier/full-jitter
+	// shape as the AWS SDK's standard retryer. Callers can substitute a
+	// wrapper around retry.NewStandard() from aws-sdk-go-v2, or any other
+	// policy that implements Backoff.
+	Backoff Backoff
+	// Endpoint overrides the default AWS endpoint, for talking to
+	// S3-compatible providers such as Minio, GCS's S3 interoperability mode,
+	// Ceph RGW, or Riak CS. It may be a bare host:port or a full URL; when no
+	// scheme is given, DisableSSL decides whether it's https or http.
+	Endpoint string
+	// DisableSSL forces a plain-http connection to Endpoint. Ignored unless
+	// Endpoint is set and has no scheme of its own.
+	DisableSSL bool
+	// S3ForcePathStyle requests path-style addressing (https://host/bucket/key)
+	// instead of the virtual-hosted style AWS uses by default. Most
+	// S3-compatible providers require this.
+	S3ForcePathStyle bool
+	// SignatureVersion selects the request signing scheme. aws-sdk-go-v2 only
+	// signs with SigV4, so the only accepted values are "" and "s3v4"; any
+	// other value (e.g. the legacy "s3v2" some old Ceph/Riak deployments
+	// still expect) is rejected by New.
+	SignatureVersion string
+	// Client lets callers inject an already-built *s3.Client (for example one
+	// pointed at a non-AWS endpoint, or wrapped with custom middleware)
+	// instead of having New load one from ID/Secret/Token/Region/Endpoint.
+	Client     *client.Client
+	Uploader   UploaderInterface
+	Downloader DownloaderInterface
+	HeadGetter HeadGetterInterface
+	HashMaker  HashMakerInterface
+	// HashConcurrency is the number of workers the default HashMaker uses to
+	// hash multipart objects' parts in parallel, which matters for ETag
+	// verification of multi-gigabyte uploads and downloads. Defaults to
+	// runtime.NumCPU(). Ignored when HashMaker is set explicitly.
+	HashConcurrency int
+	// ChecksumAlgorithm selects how Upload/Download verify content against
+	// what S3 reports back. "" and "MD5" (the default) compare against the
+	// object's ETag, which for multipart objects depends on the exact part
+	// size used — a frequent source of spurious "unmatch etag" failures
+	// against providers that don't chunk the way GetMultiPartSize predicts.
+	// "CRC32C" and "SHA256" instead use S3's additional checksums feature
+	// (see IsChecksum), which is reported directly by HeadObject. For
+	// multipart objects the part size is still guessed from the same
+	// candidates as the ETag path, so an object uploaded with a part size
+	// outside those candidates still fails verification.
+	ChecksumAlgorithm string
+	// ChecksumVerifier lets callers inject their own ChecksumVerifierInterface
+	// implementation instead of the default *ChecksumVerifier built from
+	// ChecksumAlgorithm.
+	ChecksumVerifier ChecksumVerifierInterface
 }
 
 func New(config *Config) (s3 *S3, err error) {
+	return NewWithContext(context.Background(), config)
+}
+
+func NewWithContext(ctx context.Context, config *Config) (s3 *S3, err error) {
 	config.setDefault()
 
-	creds := credentials.NewStaticCredentials(config.ID, config.Secret, config.Token)
-	region := aws.String(config.Region)
-	bucket := aws.String(config.Bucket)
-	sess, err := session.NewSession(&aws.Config{
-		Credentials: creds,
-		Region:      region,
-	})
-	if err != nil {
+	if config.SignatureVersion != "" && config.SignatureVersion != "s3v4" {
+		err = errors.New("unsupported signature version: " + config.SignatureVersion)
+		return
+	}
+
+	switch config.ChecksumAlgorithm {
+	case "MD5", "CRC32C", "SHA256":
+	default:
+		err = errors.New("unsupported checksum algorithm: " + config.ChecksumAlgorithm)
 		return
 	}
+
+	apiClient := config.Client
+	if apiClient == nil {
+		opts := []func(*awsconfig.LoadOptions) error{
+			awsconfig.WithRegion(config.Region),
+		}
+		if config.ID != "" || config.Secret != "" {
+			opts = append(opts, awsconfig.WithCredentialsProvider(
+				credentials.NewStaticCredentialsProvider(config.ID, config.Secret, config.Token),
+			))
+		}
+
+		var cfg aws.Config
+		cfg, err = awsconfig.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return
+		}
+		apiClient = client.NewFromConfig(cfg, func(o *client.Options) {
+			if config.Endpoint != "" {
+				o.BaseEndpoint = aws.String(normalizeEndpoint(config.Endpoint, config.DisableSSL))
+			}
+			if config.S3ForcePathStyle {
+				o.UsePathStyle = true
+			}
+		})
+	}
+
 	s3 = &S3{
-		credentials:  creds,
-		session:      sess,
-		region:       region,
-		bucket:       bucket,
-		s3:           client.New(sess),
-		retryCnt:     config.RetryCnt,
-		waitDuration: config.WaitDuration,
-		uploader:     config.Uploader,
-		downloader:   config.Downloader,
-		headGetter:   config.HeadGetter,
-		hashMaker:    config.HashMaker,
+		bucket:            aws.String(config.Bucket),
+		putClient:         apiClient,
+		headClient:        apiClient,
+		getClient:         apiClient,
+		retryCnt:          config.RetryCnt,
+		backoff:           config.Backoff,
+		uploader:          config.Uploader,
+		downloader:        config.Downloader,
+		headGetter:        config.HeadGetter,
+		hashMaker:         config.HashMaker,
+		checksumAlgorithm: config.ChecksumAlgorithm,
+		checksumVerifier:  config.ChecksumVerifier,
 	}
 	return
 }
@@ -97,8 +325,8 @@ func (config *Config) setDefault() {
 	if config.RetryCnt <= 0 {
 		config.RetryCnt = 3
 	}
-	if config.WaitDuration <= 0 {
-		config.WaitDuration = 3
+	if config.Backoff == nil {
+		config.Backoff = &ExponentialBackoff{}
 	}
 	if config.Uploader == nil {
 		config.Uploader = &Uploader{}
@@ -109,31 +337,166 @@ func (config *Config) setDefault() {
 	if config.HeadGetter == nil {
 		config.HeadGetter = &HeadGetter{}
 	}
+	if config.HashConcurrency <= 0 {
+		config.HashConcurrency = runtime.NumCPU()
+	}
 	if config.HashMaker == nil {
-		config.HashMaker = &HashMaker{}
+		config.HashMaker = &HashMaker{Concurrency: config.HashConcurrency}
+	}
+	if config.ChecksumAlgorithm == "" {
+		config.ChecksumAlgorithm = "MD5"
+	}
+	if config.ChecksumVerifier == nil {
+		config.ChecksumVerifier = &ChecksumVerifier{Algorithm: config.ChecksumAlgorithm}
 	}
 }
 
 func (s3 *S3) Upload(filePath, s3Path string) (err error) {
+	return s3.UploadWithContext(context.Background(), filePath, s3Path)
+}
+
+func (s3 *S3) UploadWithContext(ctx context.Context, filePath, s3Path string) (err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		e := file.Close()
+		if err == nil {
+			err = e
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	return s3.uploadWithContext(ctx, file, info.Size(), s3Path, nil)
+}
+
+// UploadStream uploads the content read from r, which need not be seekable:
+// non-seekable sources (network pipes, in-flight archive entries, and the
+// like) are spooled to a temp file so retries and ETag verification can
+// rewind them. size is the number of bytes r will yield; pass -1 if unknown.
+func (s3 *S3) UploadStream(ctx context.Context, r io.Reader, size int64, s3Path string) (err error) {
+	rs, cleanup, err := ensureSeekable(r)
+	if err != nil {
+		return
+	}
+	defer cleanup()
+
+	return s3.uploadWithContext(ctx, rs, size, s3Path, nil)
+}
+
+// UploadOptions configures a single UploadWithOptions call: server-side
+// encryption, storage class, ACL, and the other per-object settings
+// UploadInput exposes beyond bucket/key/body.
+type UploadOptions struct {
+	// ServerSideEncryption is "AES256" for SSE-S3 or "aws:kms" for SSE-KMS.
+	ServerSideEncryption string
+	// SSEKMSKeyID is the KMS key ID or ARN to use when ServerSideEncryption
+	// is "aws:kms". Leave empty to use the bucket's default KMS key.
+	SSEKMSKeyID string
+	// SSECustomerKey is the raw (not base64-encoded) customer-provided key
+	// for SSE-C. Setting it is enough to enable SSE-C; SSECustomerAlgorithm
+	// defaults to "AES256" and the required key MD5 is computed internally.
+	SSECustomerKey       string
+	SSECustomerAlgorithm string
+	StorageClass         string
+	ACL                  string
+	ContentType          string
+	Metadata             map[string]string
+	Tagging              string
+	// ChecksumAlgorithm requests one of S3's additional checksums ("CRC32C"
+	// or "SHA256") be computed by S3 on upload. It is compared against a
+	// locally computed checksum only for SSE-C and SSE-KMS uploads; other
+	// uploads are verified as Config.ChecksumAlgorithm selects. SSE-C and
+	// SSE-KMS objects don't get an MD5-shaped ETag, so when either is in use
+	// this is the only way to verify the upload; leaving it empty in that
+	// case causes verification to be skipped entirely.
+	ChecksumAlgorithm string
+}
+
+// usesCustomerOrKMSEncryption reports whether opts requests an encryption
+// mode that makes the returned ETag unusable for MD5-based verification.
+func (opts *UploadOptions) usesCustomerOrKMSEncryption() bool {
+	return opts != nil && (opts.SSECustomerKey != "" || opts.ServerSideEncryption == "aws:kms")
+}
+
+// UploadWithOptions uploads filePath to s3Path with the given UploadOptions
+// applied (SSE, storage class, ACL, content type, metadata, tagging).
+//
+// Because SSE-C and SSE-KMS objects report an ETag that isn't the plain MD5
+// of their content, IsETag can't verify them: when opts requests either, the
+// retry loop verifies the upload via opts.ChecksumAlgorithm instead if one is
+// set, and otherwise trusts the upload outcome without re-checking it.
+func (s3 *S3) UploadWithOptions(filePath, s3Path string, opts *UploadOptions) (err error) {
+	return s3.UploadWithOptionsContext(context.Background(), filePath, s3Path, opts)
+}
+
+func (s3 *S3) UploadWithOptionsContext(ctx context.Context, filePath, s3Path string, opts *UploadOptions) (err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		e := file.Close()
+		if err == nil {
+			err = e
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	return s3.uploadWithContext(ctx, file, info.Size(), s3Path, opts)
+}
+
+func (s3 *S3) uploadWithContext(ctx context.Context, r io.ReadSeeker, size int64, s3Path string, opts *UploadOptions) (err error) {
 	for i := 0; i < s3.retryCnt; i++ {
-		err = nil
+		if err = ctx.Err(); err != nil {
+			return
+		}
 
-		_, err = s3.uploader.upload(s3, filePath, s3Path)
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+
+		var out *manager.UploadOutput
+		out, err = s3.uploader.upload(ctx, s3, r, size, s3Path, opts)
 		if err != nil {
-			s3.wait()
+			if !isRetryable(err) {
+				return
+			}
+			if werr := s3.backoff.Wait(ctx, i); werr != nil {
+				err = werr
+				return
+			}
 			continue
 		}
 
-		var isETag bool
-		isETag, err = s3.IsETag(filePath, s3Path)
+		var verified bool
+		verified, err = s3.verifyUpload(ctx, r, s3Path, opts, out)
 		if err != nil {
-			s3.wait()
+			if !isRetryable(err) {
+				return
+			}
+			if werr := s3.backoff.Wait(ctx, i); werr != nil {
+				err = werr
+				return
+			}
 			continue
 		}
 
-		if !isETag {
+		if !verified {
 			err = errors.New("unmatch etag")
-			s3.wait()
+			if werr := s3.backoff.Wait(ctx, i); werr != nil {
+				err = werr
+				return
+			}
 			continue
 		}
 		break
@@ -141,64 +504,238 @@ func (s3 *S3) Upload(filePath, s3Path string) (err error) {
 	return
 }
 
-type Uploader struct {
+// verifyUpload checks that what S3 now has under s3Path matches r. Ordinary
+// uploads (opts == nil, or opts that don't change ETag semantics) are
+// verified by verifyContent as usual; SSE-C/SSE-KMS uploads fall back to
+// opts.ChecksumAlgorithm, or are trusted unverified if that's unset too.
+func (s3 *S3) verifyUpload(ctx context.Context, r io.ReadSeeker, s3Path string, opts *UploadOptions, out *manager.UploadOutput) (verified bool, err error) {
+	if !opts.usesCustomerOrKMSEncryption() {
+		return s3.verifyContent(ctx, r, s3Path)
+	}
+
+	if opts.ChecksumAlgorithm == "" {
+		return true, nil
+	}
+
+	return s3.checksumMatches(r, opts.ChecksumAlgorithm, out)
 }
 
-func (uploader *Uploader) upload(s3 *S3, filePath, s3Path string) (out *manager.UploadOutput, err error) {
-	file, err := os.Open(filePath)
+// checksumMatches compares the additional checksum S3 computed during the
+// upload (returned on out) against one computed locally from r, using the
+// same multipart-aware candidate-size matching as the Config.ChecksumAlgorithm
+// path (matchesChecksum) instead of assuming a single-part object.
+func (s3 *S3) checksumMatches(r io.ReadSeeker, algorithm string, out *manager.UploadOutput) (matches bool, err error) {
+	var remote *string
+	switch algorithm {
+	case "CRC32C":
+		remote = out.ChecksumCRC32C
+	case "SHA256":
+		remote = out.ChecksumSHA256
+	default:
+		err = errors.New("unsupported checksum algorithm: " + algorithm)
+		return
+	}
+	if out == nil || remote == nil {
+		err = errors.New("upload response did not include a " + algorithm + " checksum")
+		return
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
 	if err != nil {
 		return
 	}
-	defer func() {
-		e := file.Close()
-		if err == nil {
-			err = e
+
+	return matchesChecksum(r, &ChecksumVerifier{Algorithm: algorithm}, *remote, int(size))
+}
+
+// applyUploadOptions copies opts onto input. It is a no-op when opts is nil,
+// so the default Upload/UploadStream paths are unaffected.
+func applyUploadOptions(input *client.PutObjectInput, opts *UploadOptions) {
+	if opts == nil {
+		return
+	}
+
+	if opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(opts.ServerSideEncryption)
+	}
+	if opts.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(opts.SSEKMSKeyID)
+	}
+	if opts.SSECustomerKey != "" {
+		keyMD5 := md5.Sum([]byte(opts.SSECustomerKey)) //nolint:gosec
+		algorithm := opts.SSECustomerAlgorithm
+		if algorithm == "" {
+			algorithm = "AES256"
 		}
-	}()
+		input.SSECustomerAlgorithm = aws.String(algorithm)
+		input.SSECustomerKey = aws.String(base64.StdEncoding.EncodeToString([]byte(opts.SSECustomerKey)))
+		input.SSECustomerKeyMD5 = aws.String(base64.StdEncoding.EncodeToString(keyMD5[:]))
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = types.StorageClass(opts.StorageClass)
+	}
+	if opts.ACL != "" {
+		input.ACL = types.ObjectCannedACL(opts.ACL)
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = opts.Metadata
+	}
+	if opts.Tagging != "" {
+		input.Tagging = aws.String(opts.Tagging)
+	}
+	if opts.ChecksumAlgorithm != "" {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(opts.ChecksumAlgorithm)
+	}
+}
+
+// ensureSeekable returns r as an io.ReadSeeker, spooling it into a temp file
+// first if it isn't already one. The returned cleanup func must be called
+// once the caller is done with the reader.
+func ensureSeekable(r io.Reader) (rs io.ReadSeeker, cleanup func(), err error) {
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		return seeker, func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "go-aws-s3-upload-*")
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(tmp, r); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	rs = tmp
+	cleanup = func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+	return
+}
 
+type Uploader struct {
+}
+
+func (uploader *Uploader) upload(ctx context.Context, s3 *S3, r io.ReadSeeker, size int64, s3Path string, opts *UploadOptions) (out *manager.UploadOutput, err error) {
 	s3PathStr := aws.String(s3Path)
 
-	s3Uploader := manager.NewUploader(s3.session)
-	out, err = s3Uploader.Upload(
-		&manager.UploadInput{
-			Bucket: s3.bucket,
-			Key:    s3PathStr,
-			Body:   file,
-		},
-	)
+	input := &client.PutObjectInput{
+		Bucket: s3.bucket,
+		Key:    s3PathStr,
+		Body:   r,
+	}
+	if size >= 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	applyUploadOptions(input, opts)
+	if input.ChecksumAlgorithm == "" && s3.checksumAlgorithm != "" && s3.checksumAlgorithm != "MD5" {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(s3.checksumAlgorithm)
+	}
+
+	s3Uploader := manager.NewUploader(s3.putClient)
+	out, err = s3Uploader.Upload(ctx, input)
 	if err != nil {
 		return
 	}
 
-	err = s3.s3.WaitUntilObjectExists(
+	waiter := client.NewObjectExistsWaiter(s3.headClient)
+	err = waiter.Wait(
+		ctx,
 		&client.HeadObjectInput{
 			Bucket: s3.bucket,
 			Key:    s3PathStr,
 		},
+		objectExistsWaitTimeout,
 	)
 	return out, err
 }
 
 func (s3 *S3) Download(filePath, s3Path string) (n int64, err error) {
+	return s3.DownloadWithContext(context.Background(), filePath, s3Path)
+}
+
+func (s3 *S3) DownloadWithContext(ctx context.Context, filePath, s3Path string) (n int64, err error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		e := file.Close()
+		if err == nil {
+			err = e
+		}
+	}()
+
+	return s3.downloadWithContext(ctx, file, s3Path)
+}
+
+// DownloadStream downloads the object at s3Path into w. If w also implements
+// io.WriterAt (as *os.File does), the download is written directly into w
+// and retried in place on failure; it is verified against what S3 reports
+// (see verifyContent) only when w is also an io.ReadSeeker, and is otherwise
+// accepted unverified.
+// Otherwise (a plain network pipe or http.ResponseWriter, say) the object is
+// downloaded and verified into a temporary file first and only copied to w
+// once that succeeds, so a retry never appends a second copy after a
+// partial write.
+func (s3 *S3) DownloadStream(ctx context.Context, w io.Writer, s3Path string) (n int64, err error) {
+	return s3.downloadWithContext(ctx, w, s3Path)
+}
+
+func (s3 *S3) downloadWithContext(ctx context.Context, w io.Writer, s3Path string) (n int64, err error) {
+	// Retrying a sequential GET into a plain io.Writer would append each
+	// attempt after the last instead of overwriting it, corrupting the
+	// destination. Spool to a temp file (which satisfies io.WriterAt and
+	// io.ReadSeeker) and copy it to w only once the download has succeeded,
+	// the same way ensureSeekable spools non-seekable upload readers.
+	if _, ok := w.(io.WriterAt); !ok {
+		return s3.downloadToNonSeekable(ctx, w, s3Path)
+	}
+
 	for i := 0; i < s3.retryCnt; i++ {
-		err = nil
+		if err = ctx.Err(); err != nil {
+			return
+		}
 
-		n, err = s3.downloader.download(s3, filePath, s3Path)
+		n, err = s3.downloader.download(ctx, s3, w, s3Path)
 		if err != nil {
-			s3.wait()
+			if !isRetryable(err) {
+				return
+			}
+			if werr := s3.backoff.Wait(ctx, i); werr != nil {
+				err = werr
+				return
+			}
 			continue
 		}
 
 		var isETag bool
-		isETag, err = s3.IsETag(filePath, s3Path)
-		if err != nil {
-			s3.wait()
-			continue
+		if rs, ok := w.(io.ReadSeeker); ok {
+			isETag, err = s3.verifyContent(ctx, rs, s3Path)
+			if err != nil {
+				if !isRetryable(err) {
+					return
+				}
+				if werr := s3.backoff.Wait(ctx, i); werr != nil {
+					err = werr
+					return
+				}
+				continue
+			}
+		} else {
+			isETag = true
 		}
 
 		if !isETag {
 			err = errors.New("unmatch etag")
-			s3.wait()
+			if werr := s3.backoff.Wait(ctx, i); werr != nil {
+				err = werr
+				return
+			}
 			continue
 		}
 
@@ -207,39 +744,65 @@ func (s3 *S3) Download(filePath, s3Path string) (n int64, err error) {
 	return n, err
 }
 
-type Downloader struct {
-}
-
-func (downloader Downloader) download(s3 *S3, filePath, s3Path string) (n int64, err error) {
-	file, err := os.Create(filePath)
+func (s3 *S3) downloadToNonSeekable(ctx context.Context, w io.Writer, s3Path string) (n int64, err error) {
+	tmp, err := os.CreateTemp("", "go-aws-s3-download-*")
 	if err != nil {
 		return
 	}
 	defer func() {
-		e := file.Close()
-		if e != nil {
-			e = os.Remove(filePath)
-		}
-		if err != nil {
+		e := tmp.Close()
+		if err == nil {
 			err = e
 		}
+		_ = os.Remove(tmp.Name())
 	}()
 
-	s3Downloader := manager.NewDownloader(s3.session)
-	n, err = s3Downloader.Download(
-		file,
-		&client.GetObjectInput{
-			Bucket: s3.bucket,
-			Key:    aws.String(s3Path),
-		},
-	)
+	if n, err = s3.downloadWithContext(ctx, tmp, s3Path); err != nil {
+		return
+	}
+
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	n, err = io.Copy(w, tmp)
+	return
+}
+
+type Downloader struct {
+}
+
+func (downloader Downloader) download(ctx context.Context, s3 *S3, w io.Writer, s3Path string) (n int64, err error) {
+	input := &client.GetObjectInput{
+		Bucket: s3.bucket,
+		Key:    aws.String(s3Path),
+	}
+
+	// manager.Downloader needs io.WriterAt to issue concurrent ranged GETs.
+	// Destinations that can't provide that (a pipe, an http.ResponseWriter)
+	// fall back to a single sequential GET.
+	writerAt, ok := w.(io.WriterAt)
+	if !ok {
+		var out *client.GetObjectOutput
+		out, err = s3.getClient.GetObject(ctx, input)
+		if err != nil {
+			return
+		}
+		defer out.Body.Close()
+
+		n, err = io.Copy(w, out.Body)
+		return
+	}
+
+	s3Downloader := manager.NewDownloader(s3.getClient)
+	n, err = s3Downloader.Download(ctx, writerAt, input)
 	return
 }
 
 type HeadGetter struct{}
 
-func (headGetter *HeadGetter) get(s3 *S3, s3Path string) (out *client.HeadObjectOutput, err error) {
-	return s3.s3.HeadObject(
+func (headGetter *HeadGetter) get(ctx context.Context, s3 *S3, s3Path string) (out *client.HeadObjectOutput, err error) {
+	return s3.headClient.HeadObject(
+		ctx,
 		&client.HeadObjectInput{
 			Bucket: s3.bucket,
 			Key:    aws.String(s3Path),
@@ -248,7 +811,22 @@ func (headGetter *HeadGetter) get(s3 *S3, s3Path string) (out *client.HeadObject
 }
 
 func (s3 *S3) IsETag(filePath, s3Path string) (isETag bool, err error) {
-	out, err := s3.headGetter.get(s3, s3Path)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		e := file.Close()
+		if err == nil {
+			err = e
+		}
+	}()
+
+	return s3.hashMatchesETag(context.Background(), file, s3Path)
+}
+
+func (s3 *S3) hashMatchesETag(ctx context.Context, r io.ReadSeeker, s3Path string) (isETag bool, err error) {
+	out, err := s3.headGetter.get(ctx, s3, s3Path)
 	if err != nil {
 		return
 	}
@@ -256,40 +834,188 @@ func (s3 *S3) IsETag(filePath, s3Path string) (isETag bool, err error) {
 	eTag := strings.Trim(*out.ETag, "\" ")
 	fileSize := int(*out.ContentLength)
 
-	isETag, err = s3.isETag(filePath, eTag, fileSize)
+	isETag, err = s3.isETag(r, eTag, fileSize)
 	return
 }
 
-func (s3 *S3) wait() {
-	time.Sleep(s3.waitDuration * time.Second)
+// IsChecksum reports whether the local content at filePath matches the
+// additional checksum (CRC32C or SHA256, per s3.checksumAlgorithm) S3 reports
+// for s3Path. Unlike IsETag, it requires Config.ChecksumAlgorithm to have
+// been set to "CRC32C" or "SHA256".
+func (s3 *S3) IsChecksum(filePath, s3Path string) (isChecksum bool, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		e := file.Close()
+		if err == nil {
+			err = e
+		}
+	}()
+
+	return s3.hashMatchesChecksum(context.Background(), file, s3Path, s3.checksumAlgorithm)
 }
 
-func (s3 *S3) isETag(filePath, eTag string, fileSize int) (isETag bool, err error) {
+// verifyContent compares r against what S3 reports for s3Path, using whichever
+// algorithm s3.checksumAlgorithm selects: the MD5-based ETag by default, or
+// one of S3's additional checksums when configured to avoid ETag's
+// part-size-dependent multipart behaviour.
+func (s3 *S3) verifyContent(ctx context.Context, r io.ReadSeeker, s3Path string) (verified bool, err error) {
+	if s3.checksumAlgorithm == "" || s3.checksumAlgorithm == "MD5" {
+		return s3.hashMatchesETag(ctx, r, s3Path)
+	}
+	return s3.hashMatchesChecksum(ctx, r, s3Path, s3.checksumAlgorithm)
+}
+
+func (s3 *S3) hashMatchesChecksum(ctx context.Context, r io.ReadSeeker, s3Path, algorithm string) (matches bool, err error) {
+	out, err := s3.headClient.HeadObject(
+		ctx,
+		&client.HeadObjectInput{
+			Bucket:       s3.bucket,
+			Key:          aws.String(s3Path),
+			ChecksumMode: types.ChecksumModeEnabled,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	var remote *string
+	switch algorithm {
+	case "CRC32C":
+		remote = out.ChecksumCRC32C
+	case "SHA256":
+		remote = out.ChecksumSHA256
+	default:
+		err = errors.New("unsupported checksum algorithm: " + algorithm)
+		return
+	}
+	if remote == nil {
+		err = errors.New("object has no " + algorithm + " checksum")
+		return
+	}
+
+	fileSize := int(*out.ContentLength)
+	matches, err = s3.isChecksum(r, *remote, fileSize)
+	return
+}
+
+func (s3 *S3) isETag(r io.ReadSeeker, eTag string, fileSize int) (isETag bool, err error) {
 	hash, partCnt, err := GetETagHashAndPartCnt(eTag)
 	if err != nil {
 		return
 	}
 
-	var fileHash string
-	if partCnt > 1 {
-		var partSize int
-		partSize, err = GetMultiPartSize(fileSize, partCnt)
+	if partCnt <= 1 {
+		var fileHash string
+		fileHash, err = s3.hashMaker.makeSinglePart(r)
 		if err != nil {
 			return
 		}
+		isETag = fileHash == hash
+		return
+	}
 
-		fileHash, err = s3.hashMaker.makeMultiPartFromFile(filePath, partSize)
-	} else {
-		fileHash, err = s3.hashMaker.makeSinglePartFromFile(filePath)
+	// The part size implied by a multipart ETag isn't recoverable from the
+	// ETag alone, and not every S3-compatible provider chunks uploads the
+	// way AWS's own SDKs do (some Minio versions, for instance, default to a
+	// different part size). Try GetMultiPartSize's AWS-shaped guess first,
+	// then a handful of other part sizes that are common in the wild, before
+	// giving up.
+	for _, partSize := range candidateMultiPartSizes(fileSize, partCnt) {
+		var fileHash string
+		fileHash, err = s3.hashMaker.makeMultiPart(r, partSize)
+		if err != nil {
+			return
+		}
+		if fileHash == hash {
+			isETag = true
+			return
+		}
 	}
+	return
+}
+
+// isChecksum mirrors isETag, but compares against one of S3's additional
+// checksums (reported base64-encoded, optionally "-N" suffixed for multipart
+// objects in the same shape as an ETag) instead of the MD5 ETag.
+func (s3 *S3) isChecksum(r io.ReadSeeker, checksum string, fileSize int) (matches bool, err error) {
+	return matchesChecksum(r, s3.checksumVerifier, checksum, fileSize)
+}
+
+// matchesChecksum is isChecksum's verifier-agnostic core, shared with
+// checksumMatches so SSE-C/SSE-KMS uploads get the same multipart-aware
+// candidate-size matching as the Config.ChecksumAlgorithm path instead of a
+// separate, simpler implementation.
+func matchesChecksum(r io.ReadSeeker, verifier ChecksumVerifierInterface, checksum string, fileSize int) (matches bool, err error) {
+	hash, partCnt, err := GetETagHashAndPartCnt(checksum)
 	if err != nil {
 		return
 	}
 
-	isETag = fileHash == hash
+	if partCnt <= 1 {
+		var fileChecksum string
+		fileChecksum, err = verifier.makeSinglePart(r)
+		if err != nil {
+			return
+		}
+		matches = fileChecksum == hash
+		return
+	}
+
+	for _, partSize := range candidateMultiPartSizes(fileSize, partCnt) {
+		var fileChecksum string
+		fileChecksum, err = verifier.makeMultiPart(r, partSize)
+		if err != nil {
+			return
+		}
+		if fileChecksum == hash {
+			matches = true
+			return
+		}
+	}
 	return
 }
 
+// commonPartSizesMB are multipart part sizes, in megabytes, seen in the wild
+// on S3-compatible providers whose default differs from AWS's.
+var commonPartSizesMB = []int{5, 8, 16, 32, 64}
+
+func candidateMultiPartSizes(fileSize, partCnt int) (partSizes []int) {
+	seen := make(map[int]bool, len(commonPartSizesMB)+1)
+	add := func(partSize int) {
+		if partSize <= 0 || seen[partSize] {
+			return
+		}
+		seen[partSize] = true
+		partSizes = append(partSizes, partSize)
+	}
+
+	if primary, err := GetMultiPartSize(fileSize, partCnt); err == nil {
+		add(primary)
+	}
+
+	for _, mb := range commonPartSizesMB {
+		partSize := mb * megabyte
+		if partCntForSize(fileSize, partSize) == partCnt {
+			add(partSize)
+		}
+	}
+	return
+}
+
+func partCntForSize(fileSize, partSize int) int {
+	if partSize <= 0 {
+		return 0
+	}
+	cnt := fileSize / partSize
+	if fileSize%partSize > 0 {
+		cnt++
+	}
+	return cnt
+}
+
 func GetETagHashAndPartCnt(eTag string) (hash string, partCnt int, err error) {
 	splitted := strings.Split(eTag, "-")
 	if eTag == "" {
@@ -323,24 +1049,42 @@ func GetMultiPartSize(fileSize, partCnt int) (partSize int, err error) {
 	return
 }
 
-type HashMaker struct{}
+// HashMaker computes the MD5-based hash S3 uses for ETags.
+type HashMaker struct {
+	// Concurrency is the number of worker goroutines makeMultiPart uses to
+	// hash parts in parallel when r also implements io.ReaderAt (as *os.File
+	// does). <= 0 means runtime.GOMAXPROCS(0). Ignored when r doesn't
+	// support io.ReaderAt, in which case parts are hashed serially.
+	Concurrency int
+}
 
-func (hashMaker *HashMaker) makeMultiPartFromFile(filePath string, partSize int) (hash string, err error) {
-	file, err := os.Open(filePath)
-	if err != nil {
+// makeMultiPart hashes r in partSize chunks the way S3 composes a multipart
+// ETag: each part's MD5 digest is concatenated, and the MD5 of that
+// concatenation is the result. When r implements io.ReaderAt, parts are
+// hashed concurrently by a pool of hashMaker.Concurrency workers, each
+// reading its own part via io.NewSectionReader; results are assembled back
+// in part order, so the concurrency is invisible in the returned hash.
+func (hashMaker *HashMaker) makeMultiPart(r io.ReadSeeker, partSize int) (hash string, err error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		var size int64
+		size, err = r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return
+		}
+		return hashMaker.makeMultiPartConcurrent(ra, size, partSize)
+	}
+	return hashMaker.makeMultiPartSerial(r, partSize)
+}
+
+func (hashMaker *HashMaker) makeMultiPartSerial(r io.ReadSeeker, partSize int) (hash string, err error) {
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
 		return
 	}
-	defer func() {
-		e := file.Close()
-		if err == nil {
-			err = e
-		}
-	}()
 
 	b := make([]byte, partSize)
 	h := make([]byte, 0, 128)
 	for {
-		n, e := file.Read(b)
+		n, e := r.Read(b)
 		if e == io.EOF {
 			break
 		}
@@ -356,20 +1100,84 @@ func (hashMaker *HashMaker) makeMultiPartFromFile(filePath string, partSize int)
 	return
 }
 
-func (hashMaker *HashMaker) makeSinglePartFromFile(filePath string) (hash string, err error) {
-	f, err := os.Open(filePath)
-	if err != nil {
+func (hashMaker *HashMaker) makeMultiPartConcurrent(ra io.ReaderAt, size int64, partSize int) (hash string, err error) {
+	if partSize <= 0 {
+		err = errors.New("invalid part size")
 		return
 	}
-	defer func() {
-		e := f.Close()
-		if err == nil {
+
+	partCnt := int(size / int64(partSize))
+	if size%int64(partSize) > 0 {
+		partCnt++
+	}
+	if partCnt == 0 {
+		sum := md5.Sum(nil) //nolint:gosec
+		hash = getMd5FromBytes(sum[:16])
+		return
+	}
+
+	concurrency := hashMaker.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	if concurrency > partCnt {
+		concurrency = partCnt
+	}
+
+	digests := make([][md5.Size]byte, partCnt)
+	errs := make([]error, partCnt)
+
+	parts := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go func() {
+			defer wg.Done()
+			for partIdx := range parts {
+				offset := int64(partIdx) * int64(partSize)
+				length := int64(partSize)
+				if offset+length > size {
+					length = size - offset
+				}
+
+				h := md5.New() //nolint:gosec
+				if _, e := io.Copy(h, io.NewSectionReader(ra, offset, length)); e != nil {
+					errs[partIdx] = e
+					continue
+				}
+				copy(digests[partIdx][:], h.Sum(nil))
+			}
+		}()
+	}
+	for partIdx := 0; partIdx < partCnt; partIdx++ {
+		parts <- partIdx
+	}
+	close(parts)
+	wg.Wait()
+
+	for _, e := range errs {
+		if e != nil {
 			err = e
+			return
 		}
-	}()
+	}
+
+	h := make([]byte, 0, partCnt*md5.Size)
+	for _, d := range digests {
+		h = append(h, d[:]...)
+	}
+	sum := md5.Sum(h) //nolint:gosec
+	hash = getMd5FromBytes(sum[:16])
+	return
+}
+
+func (hashMaker *HashMaker) makeSinglePart(r io.ReadSeeker) (hash string, err error) {
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return
+	}
 
 	h := md5.New() //nolint:gosec
-	_, err = io.Copy(h, f)
+	_, err = io.Copy(h, r)
 	if err != nil {
 		return
 	}
@@ -380,3 +1188,76 @@ func (hashMaker *HashMaker) makeSinglePartFromFile(filePath string) (hash string
 func getMd5FromBytes(b []byte) string {
 	return hex.EncodeToString(b)
 }
+
+// ChecksumVerifier computes one of S3's additional checksums (Algorithm is
+// "CRC32C" or "SHA256") the same way S3 does, for comparison against the
+// checksum S3 reports back from HeadObject. Multipart objects are hashed the
+// same way the ETag is: per-part digests are concatenated and hashed again,
+// with an "-N" part count suffix.
+type ChecksumVerifier struct {
+	Algorithm string
+}
+
+func (cv *ChecksumVerifier) newHash() (h hash.Hash, err error) {
+	switch cv.Algorithm {
+	case "CRC32C":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	case "SHA256":
+		return sha256.New(), nil
+	default:
+		return nil, errors.New("unsupported checksum algorithm: " + cv.Algorithm)
+	}
+}
+
+func (cv *ChecksumVerifier) makeMultiPart(r io.ReadSeeker, partSize int) (checksum string, err error) {
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	h, err := cv.newHash()
+	if err != nil {
+		return
+	}
+
+	b := make([]byte, partSize)
+	digests := make([]byte, 0, 128)
+	for {
+		n, e := r.Read(b)
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			err = e
+			return
+		}
+		h.Reset()
+		if _, err = h.Write(b[:n]); err != nil {
+			return
+		}
+		digests = append(digests, h.Sum(nil)...)
+	}
+
+	h.Reset()
+	if _, err = h.Write(digests); err != nil {
+		return
+	}
+	checksum = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return
+}
+
+func (cv *ChecksumVerifier) makeSinglePart(r io.ReadSeeker) (checksum string, err error) {
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	h, err := cv.newHash()
+	if err != nil {
+		return
+	}
+
+	if _, err = io.Copy(h, r); err != nil {
+		return
+	}
+	checksum = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return
+}
@@ -1,10 +1,26 @@
 package s3
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	client "github.com/aws/aws-sdk-go/service/s3"
-	manager "github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	client "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithy "github.com/aws/smithy-go"
 )
 
 type HeadGetterTest struct {
@@ -18,7 +34,7 @@ type HeadGetterTestValue struct {
 	Err error
 }
 
-func (hgt *HeadGetterTest) get(s3 *S3, s3Path string) (out *client.HeadObjectOutput, err error) {
+func (hgt *HeadGetterTest) get(ctx context.Context, s3 *S3, s3Path string) (out *client.HeadObjectOutput, err error) {
 	out = &hgt.Values[hgt.CurrentPosition].Out
 	err = hgt.Values[hgt.CurrentPosition].Err
 	hgt.CurrentPosition++
@@ -36,21 +52,20 @@ type HashMakerTestValue struct {
 	Err  error
 }
 
-func (hmt *HashMakerTest) makeMultiPartFromFile(filePath string, partSize int) (hash string, err error) {
+func (hmt *HashMakerTest) makeMultiPart(r io.ReadSeeker, partSize int) (hash string, err error) {
 	hash = hmt.Values[hmt.CurrentPosition].Hash
 	err = hmt.Values[hmt.CurrentPosition].Err
 	hmt.CurrentPosition++
 	return
 }
 
-func (hmt *HashMakerTest) makeSinglePartFromFile(filePath string) (hash string, err error) {
+func (hmt *HashMakerTest) makeSinglePart(r io.ReadSeeker) (hash string, err error) {
 	hash = hmt.Values[hmt.CurrentPosition].Hash
 	err = hmt.Values[hmt.CurrentPosition].Err
 	hmt.CurrentPosition++
 	return
 }
 
-
 type UploaderTest struct {
 	Values           []UploaderTestValue
 	CurrentPosition  int
@@ -62,7 +77,7 @@ type UploaderTestValue struct {
 	Err error
 }
 
-func (ut *UploaderTest) upload(s3 *S3, filePath, s3Path string) (out *manager.UploadOutput, err error) {
+func (ut *UploaderTest) upload(ctx context.Context, s3 *S3, r io.ReadSeeker, size int64, s3Path string, opts *UploadOptions) (out *manager.UploadOutput, err error) {
 	out = &ut.Values[ut.CurrentPosition].Out
 	err = ut.Values[ut.CurrentPosition].Err
 	ut.CurrentPosition++
@@ -217,9 +232,14 @@ func TestUpload(t *testing.T) {
 			errors.New("unmatch etag"),
 		},
 	}
+	uploadFilePath := filepath.Join(t.TempDir(), "upload-src")
+	if err := os.WriteFile(uploadFilePath, []byte("content"), 0o600); err != nil {
+		t.Fatalf("could not create upload source file: %v", err)
+	}
+
 	for _, c := range cases {
 		s3, err := New(&Config{
-			Id:         "ID",
+			ID:         "ID",
 			Uploader:   &c.uploader,
 			HeadGetter: &c.headGetter,
 			HashMaker:  &c.hashMaker,
@@ -229,7 +249,7 @@ func TestUpload(t *testing.T) {
 			return
 		}
 
-		err = s3.Upload("filePath", "s3Path")
+		err = s3.Upload(uploadFilePath, "s3Path")
 
 		// エラーが期待値と一致するか
 		if c.err != nil {
@@ -259,6 +279,373 @@ func TestUpload(t *testing.T) {
 	}
 }
 
+// nonSeekableReader wraps r so it only exposes io.Reader, hiding any
+// io.ReadSeeker the underlying reader implements, the same way a network
+// pipe or an in-flight archive entry would look to ensureSeekable.
+type nonSeekableReader struct {
+	r io.Reader
+}
+
+func (nsr *nonSeekableReader) Read(p []byte) (n int, err error) {
+	return nsr.r.Read(p)
+}
+
+func TestEnsureSeekable(t *testing.T) {
+	t.Run("すでにio.ReadSeekerならそのまま返す", func(t *testing.T) {
+		r := bytes.NewReader([]byte("content"))
+		rs, cleanup, err := ensureSeekable(r)
+		defer cleanup()
+		if err != nil {
+			t.Fatalf("err expected:nil, actual:%v", err)
+		}
+		if rs != io.ReadSeeker(r) {
+			t.Error("ensureSeekable expected to return the same ReadSeeker unchanged")
+		}
+	})
+
+	t.Run("io.ReadSeekerでなければ一時ファイルにスプールする", func(t *testing.T) {
+		content := []byte("stream content")
+		r := &nonSeekableReader{r: bytes.NewReader(content)}
+
+		rs, cleanup, err := ensureSeekable(r)
+		if err != nil {
+			t.Fatalf("err expected:nil, actual:%v", err)
+		}
+		defer cleanup()
+
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			t.Fatalf("Seek err expected:nil, actual:%v", err)
+		}
+
+		got, err := io.ReadAll(rs)
+		if err != nil {
+			t.Fatalf("ReadAll err expected:nil, actual:%v", err)
+		}
+		if string(got) != string(content) {
+			t.Errorf("spooled content expected:%v, actual:%v", string(content), string(got))
+		}
+
+		tmp, ok := rs.(*os.File)
+		if !ok {
+			t.Fatalf("spooled reader expected to be *os.File, actual:%T", rs)
+		}
+		cleanup()
+		if _, err := os.Stat(tmp.Name()); !os.IsNotExist(err) {
+			t.Errorf("cleanup expected to remove %v, stat err:%v", tmp.Name(), err)
+		}
+	})
+}
+
+func TestUploadStream(t *testing.T) {
+	content := []byte("stream content")
+	eTag := "  \"" + md5Hex(t, content) + "\"  "
+	contentLength := int64(len(content))
+
+	cases := []struct {
+		describe   string
+		uploader   UploaderTest
+		headGetter HeadGetterTest
+		hashMaker  HashMakerTest
+		err        error
+	}{
+		{
+			"非seekableなreaderでも最初のアップロードに成功する",
+			UploaderTest{
+				[]UploaderTestValue{
+					{manager.UploadOutput{}, nil},
+				},
+				0,
+				1,
+			},
+			HeadGetterTest{
+				[]HeadGetterTestValue{
+					{client.HeadObjectOutput{ETag: &eTag, ContentLength: &contentLength}, nil},
+				},
+				0,
+				1,
+			},
+			HashMakerTest{
+				[]HashMakerTestValue{
+					{md5Hex(t, content), nil},
+				},
+				0,
+				1,
+			},
+			nil,
+		},
+		{
+			"非seekableなreaderでも最初のアップロードに失敗して2回目で成功する",
+			UploaderTest{
+				[]UploaderTestValue{
+					{manager.UploadOutput{}, errors.New("upload error")},
+					{manager.UploadOutput{}, nil},
+				},
+				0,
+				2,
+			},
+			HeadGetterTest{
+				[]HeadGetterTestValue{
+					{client.HeadObjectOutput{ETag: &eTag, ContentLength: &contentLength}, nil},
+				},
+				0,
+				1,
+			},
+			HashMakerTest{
+				[]HashMakerTestValue{
+					{md5Hex(t, content), nil},
+				},
+				0,
+				1,
+			},
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+		s3, err := New(&Config{
+			ID:         "ID",
+			Uploader:   &c.uploader,
+			HeadGetter: &c.headGetter,
+			HashMaker:  &c.hashMaker,
+		})
+		if err != nil {
+			t.Errorf("could not create s3")
+			return
+		}
+
+		r := &nonSeekableReader{r: bytes.NewReader(content)}
+		err = s3.UploadStream(context.Background(), r, int64(len(content)), "s3Path")
+
+		if c.err != nil {
+			if err == nil || err.Error() != c.err.Error() {
+				t.Errorf("%v err expected:%v, actual:%v", c.describe, c.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("%v err expected:nil, actual:%v", c.describe, err)
+		}
+
+		if c.uploader.ExpectedPosition != c.uploader.CurrentPosition {
+			t.Errorf("%v uploader counter expected:%v, actual:%v", c.describe, c.uploader.ExpectedPosition, c.uploader.CurrentPosition)
+		}
+		if c.headGetter.ExpectedPosition != c.headGetter.CurrentPosition {
+			t.Errorf("%v headGetter counter expected:%v, actual:%v", c.describe, c.headGetter.ExpectedPosition, c.headGetter.CurrentPosition)
+		}
+		if c.hashMaker.ExpectedPosition != c.hashMaker.CurrentPosition {
+			t.Errorf("%v hashMaker counter expected:%v, actual:%v", c.describe, c.hashMaker.ExpectedPosition, c.hashMaker.CurrentPosition)
+		}
+	}
+}
+
+func TestUsesCustomerOrKMSEncryption(t *testing.T) {
+	cases := []struct {
+		describe string
+		opts     *UploadOptions
+		want     bool
+	}{
+		{"optsがnilなら対象外", nil, false},
+		{"暗号化を指定しなければ対象外", &UploadOptions{}, false},
+		{"SSE-S3(AES256)は対象外", &UploadOptions{ServerSideEncryption: "AES256"}, false},
+		{"SSE-KMSは対象", &UploadOptions{ServerSideEncryption: "aws:kms"}, true},
+		{"SSE-Cは対象", &UploadOptions{SSECustomerKey: "customer-key"}, true},
+	}
+	for _, c := range cases {
+		if got := c.opts.usesCustomerOrKMSEncryption(); got != c.want {
+			t.Errorf("%v expected:%v, actual:%v", c.describe, c.want, got)
+		}
+	}
+}
+
+func TestApplyUploadOptions(t *testing.T) {
+	t.Run("optsがnilなら何もしない", func(t *testing.T) {
+		input := &client.PutObjectInput{}
+		applyUploadOptions(input, nil)
+		if input.ServerSideEncryption != "" || input.SSEKMSKeyId != nil || input.SSECustomerKey != nil ||
+			input.StorageClass != "" || input.ACL != "" || input.ContentType != nil ||
+			input.Metadata != nil || input.Tagging != nil || input.ChecksumAlgorithm != "" {
+			t.Errorf("input expected to be left unchanged, actual:%+v", input)
+		}
+	})
+
+	t.Run("各フィールドがPutObjectInputへマッピングされる", func(t *testing.T) {
+		opts := &UploadOptions{
+			ServerSideEncryption: "aws:kms",
+			SSEKMSKeyID:          "key-id",
+			StorageClass:         "STANDARD_IA",
+			ACL:                  "private",
+			ContentType:          "text/plain",
+			Metadata:             map[string]string{"foo": "bar"},
+			Tagging:              "key=value",
+			ChecksumAlgorithm:    "SHA256",
+		}
+		input := &client.PutObjectInput{}
+		applyUploadOptions(input, opts)
+
+		if input.ServerSideEncryption != types.ServerSideEncryption(opts.ServerSideEncryption) {
+			t.Errorf("ServerSideEncryption expected:%v, actual:%v", opts.ServerSideEncryption, input.ServerSideEncryption)
+		}
+		if input.SSEKMSKeyId == nil || *input.SSEKMSKeyId != opts.SSEKMSKeyID {
+			t.Errorf("SSEKMSKeyId expected:%v, actual:%v", opts.SSEKMSKeyID, input.SSEKMSKeyId)
+		}
+		if input.StorageClass != types.StorageClass(opts.StorageClass) {
+			t.Errorf("StorageClass expected:%v, actual:%v", opts.StorageClass, input.StorageClass)
+		}
+		if input.ACL != types.ObjectCannedACL(opts.ACL) {
+			t.Errorf("ACL expected:%v, actual:%v", opts.ACL, input.ACL)
+		}
+		if input.ContentType == nil || *input.ContentType != opts.ContentType {
+			t.Errorf("ContentType expected:%v, actual:%v", opts.ContentType, input.ContentType)
+		}
+		if input.Metadata["foo"] != "bar" {
+			t.Errorf("Metadata expected:%v, actual:%v", opts.Metadata, input.Metadata)
+		}
+		if input.Tagging == nil || *input.Tagging != opts.Tagging {
+			t.Errorf("Tagging expected:%v, actual:%v", opts.Tagging, input.Tagging)
+		}
+		if input.ChecksumAlgorithm != types.ChecksumAlgorithm(opts.ChecksumAlgorithm) {
+			t.Errorf("ChecksumAlgorithm expected:%v, actual:%v", opts.ChecksumAlgorithm, input.ChecksumAlgorithm)
+		}
+		if input.SSECustomerKey != nil {
+			t.Errorf("SSECustomerKey expected:nil, actual:%v", input.SSECustomerKey)
+		}
+	})
+
+	t.Run("SSE-Cのキーとアルゴリズムのデフォルト値が設定される", func(t *testing.T) {
+		input := &client.PutObjectInput{}
+		applyUploadOptions(input, &UploadOptions{SSECustomerKey: "customer-key"})
+
+		if input.SSECustomerAlgorithm == nil || *input.SSECustomerAlgorithm != "AES256" {
+			t.Errorf("SSECustomerAlgorithm expected:AES256, actual:%v", input.SSECustomerAlgorithm)
+		}
+		wantKey := base64.StdEncoding.EncodeToString([]byte("customer-key"))
+		if input.SSECustomerKey == nil || *input.SSECustomerKey != wantKey {
+			t.Errorf("SSECustomerKey expected:%v, actual:%v", wantKey, input.SSECustomerKey)
+		}
+		keyMD5 := md5.Sum([]byte("customer-key")) //nolint:gosec
+		wantKeyMD5 := base64.StdEncoding.EncodeToString(keyMD5[:])
+		if input.SSECustomerKeyMD5 == nil || *input.SSECustomerKeyMD5 != wantKeyMD5 {
+			t.Errorf("SSECustomerKeyMD5 expected:%v, actual:%v", wantKeyMD5, input.SSECustomerKeyMD5)
+		}
+	})
+
+	t.Run("SSECustomerAlgorithmを明示すればそちらが使われる", func(t *testing.T) {
+		input := &client.PutObjectInput{}
+		applyUploadOptions(input, &UploadOptions{SSECustomerKey: "customer-key", SSECustomerAlgorithm: "AES256-custom"})
+
+		if input.SSECustomerAlgorithm == nil || *input.SSECustomerAlgorithm != "AES256-custom" {
+			t.Errorf("SSECustomerAlgorithm expected:AES256-custom, actual:%v", input.SSECustomerAlgorithm)
+		}
+	})
+}
+
+func TestChecksumMatches(t *testing.T) {
+	s3, err := New(&Config{ID: "ID"})
+	if err != nil {
+		t.Fatalf("could not create s3: %v", err)
+	}
+
+	data := []byte("hello world")
+
+	t.Run("単一パートのチェックサムが一致する", func(t *testing.T) {
+		out := &manager.UploadOutput{ChecksumSHA256: aws.String(expectedSinglePartChecksum("SHA256", data))}
+		matches, err := s3.checksumMatches(bytes.NewReader(data), "SHA256", out)
+		if err != nil {
+			t.Fatalf("err expected:nil, actual:%v", err)
+		}
+		if !matches {
+			t.Error("matches expected:true, actual:false")
+		}
+	})
+
+	t.Run("チェックサムが一致しない", func(t *testing.T) {
+		out := &manager.UploadOutput{ChecksumCRC32C: aws.String(expectedSinglePartChecksum("CRC32C", []byte("different")))}
+		matches, err := s3.checksumMatches(bytes.NewReader(data), "CRC32C", out)
+		if err != nil {
+			t.Fatalf("err expected:nil, actual:%v", err)
+		}
+		if matches {
+			t.Error("matches expected:false, actual:true")
+		}
+	})
+
+	t.Run("未対応のアルゴリズムはエラーになる", func(t *testing.T) {
+		out := &manager.UploadOutput{}
+		if _, err := s3.checksumMatches(bytes.NewReader(data), "MD5", out); err == nil {
+			t.Error("err expected:non-nil, actual:nil")
+		}
+	})
+
+	t.Run("アップロード結果にチェックサムが含まれていなければエラーになる", func(t *testing.T) {
+		out := &manager.UploadOutput{}
+		if _, err := s3.checksumMatches(bytes.NewReader(data), "SHA256", out); err == nil {
+			t.Error("err expected:non-nil, actual:nil")
+		}
+	})
+}
+
+func TestVerifyUpload(t *testing.T) {
+	eTag := "  \"" + md5Hex(t, []byte("content")) + "\"  "
+	contentLength := int64(len("content"))
+
+	t.Run("optsがnilならETagで検証する", func(t *testing.T) {
+		headGetter := &HeadGetterTest{Values: []HeadGetterTestValue{
+			{client.HeadObjectOutput{ETag: &eTag, ContentLength: &contentLength}, nil},
+		}}
+		hashMaker := &HashMakerTest{Values: []HashMakerTestValue{{md5Hex(t, []byte("content")), nil}}}
+		s3, err := New(&Config{ID: "ID", HeadGetter: headGetter, HashMaker: hashMaker})
+		if err != nil {
+			t.Fatalf("could not create s3: %v", err)
+		}
+
+		verified, err := s3.verifyUpload(context.Background(), bytes.NewReader([]byte("content")), "s3Path", nil, &manager.UploadOutput{})
+		if err != nil {
+			t.Fatalf("err expected:nil, actual:%v", err)
+		}
+		if !verified {
+			t.Error("verified expected:true, actual:false")
+		}
+	})
+
+	t.Run("SSE-KMSでChecksumAlgorithm未指定なら検証せず信頼する", func(t *testing.T) {
+		s3, err := New(&Config{ID: "ID"})
+		if err != nil {
+			t.Fatalf("could not create s3: %v", err)
+		}
+
+		opts := &UploadOptions{ServerSideEncryption: "aws:kms"}
+		verified, err := s3.verifyUpload(context.Background(), bytes.NewReader([]byte("content")), "s3Path", opts, &manager.UploadOutput{})
+		if err != nil {
+			t.Fatalf("err expected:nil, actual:%v", err)
+		}
+		if !verified {
+			t.Error("verified expected:true, actual:false")
+		}
+	})
+
+	t.Run("SSE-Cかつ ChecksumAlgorithm指定時はチェックサムで検証する", func(t *testing.T) {
+		s3, err := New(&Config{ID: "ID"})
+		if err != nil {
+			t.Fatalf("could not create s3: %v", err)
+		}
+
+		data := []byte("content")
+		opts := &UploadOptions{SSECustomerKey: "customer-key", ChecksumAlgorithm: "CRC32C"}
+		out := &manager.UploadOutput{ChecksumCRC32C: aws.String(expectedSinglePartChecksum("CRC32C", data))}
+
+		verified, err := s3.verifyUpload(context.Background(), bytes.NewReader(data), "s3Path", opts, out)
+		if err != nil {
+			t.Fatalf("err expected:nil, actual:%v", err)
+		}
+		if !verified {
+			t.Error("verified expected:true, actual:false")
+		}
+	})
+}
+
+func md5Hex(t *testing.T, b []byte) string {
+	t.Helper()
+	sum := md5.Sum(b) //nolint:gosec
+	return hex.EncodeToString(sum[:])
+}
 
 type DownloaderTest struct {
 	Values           []DownloaderTestValue
@@ -269,11 +656,24 @@ type DownloaderTest struct {
 type DownloaderTestValue struct {
 	Out int64
 	Err error
+	// Bytes, when set, is written into w the same way manager.Downloader
+	// writes into a WriterAt destination: at offset 0, so that repeated
+	// attempts overwrite rather than append. Existing cases that leave this
+	// nil write nothing, matching the previous behaviour.
+	Bytes []byte
 }
 
-func (dt *DownloaderTest) download(s3 *S3, filePath, s3Path string) (out int64, err error) {
-	out = dt.Values[dt.CurrentPosition].Out
-	err = dt.Values[dt.CurrentPosition].Err
+func (dt *DownloaderTest) download(ctx context.Context, s3 *S3, w io.Writer, s3Path string) (out int64, err error) {
+	v := dt.Values[dt.CurrentPosition]
+	if v.Bytes != nil {
+		if wa, ok := w.(io.WriterAt); ok {
+			_, _ = wa.WriteAt(v.Bytes, 0)
+		} else {
+			_, _ = w.Write(v.Bytes)
+		}
+	}
+	out = v.Out
+	err = v.Err
 	dt.CurrentPosition++
 	return
 }
@@ -297,10 +697,12 @@ func TestDownload(t *testing.T) {
 					{
 						0,
 						errors.New("upload error"),
+						nil,
 					},
 					{
 						99,
 						nil,
+						nil,
 					},
 				},
 				0,
@@ -338,14 +740,17 @@ func TestDownload(t *testing.T) {
 					{
 						0,
 						errors.New("downloader error"),
+						nil,
 					},
 					{
 						99,
 						nil,
+						nil,
 					},
 					{
 						99,
 						nil,
+						nil,
 					},
 				},
 				0,
@@ -387,14 +792,17 @@ func TestDownload(t *testing.T) {
 					{
 						0,
 						errors.New("upload error"),
+						nil,
 					},
 					{
 						0,
 						errors.New("upload error"),
+						nil,
 					},
 					{
 						99,
 						nil,
+						nil,
 					},
 				},
 				0,
@@ -426,10 +834,12 @@ func TestDownload(t *testing.T) {
 			errors.New("unmatch etag"),
 		},
 	}
+	downloadFilePath := filepath.Join(t.TempDir(), "download-dst")
+
 	for _, c := range cases {
 		s3, err := New(&Config{
-			Id:         "ID",
-			Downloader:   &c.downloader,
+			ID:         "ID",
+			Downloader: &c.downloader,
 			HeadGetter: &c.headGetter,
 			HashMaker:  &c.hashMaker,
 		})
@@ -438,7 +848,7 @@ func TestDownload(t *testing.T) {
 			return
 		}
 
-		_, err = s3.Download("filePath", "s3Path")
+		_, err = s3.Download(downloadFilePath, "s3Path")
 
 		// エラーが期待値と一致するか
 		if c.err != nil {
@@ -468,6 +878,81 @@ func TestDownload(t *testing.T) {
 	}
 }
 
+// TestDownloadStreamNonSeekableDestination is a regression test for 12e9e4c:
+// retrying a sequential GET into a destination that is a plain io.Writer (not
+// io.WriterAt) must not append each attempt after the last, which would
+// corrupt the destination with duplicated/partial data.
+func TestDownloadStreamNonSeekableDestination(t *testing.T) {
+	content := []byte("stream content")
+	eTag := "  \"" + md5Hex(t, content) + "\"  "
+	contentLength := int64(len(content))
+
+	downloader := &DownloaderTest{
+		[]DownloaderTestValue{
+			{
+				0,
+				errors.New("downloader error"),
+				content[:5], // a partial write before the failure
+			},
+			{
+				int64(len(content)),
+				nil,
+				content,
+			},
+		},
+		0,
+		2,
+	}
+	headGetter := &HeadGetterTest{
+		[]HeadGetterTestValue{
+			{
+				client.HeadObjectOutput{ETag: &eTag, ContentLength: &contentLength},
+				nil,
+			},
+		},
+		0,
+		1,
+	}
+	hashMaker := &HashMakerTest{
+		[]HashMakerTestValue{
+			{md5Hex(t, content), nil},
+		},
+		0,
+		1,
+	}
+
+	s3, err := New(&Config{
+		ID:         "ID",
+		Downloader: downloader,
+		HeadGetter: headGetter,
+		HashMaker:  hashMaker,
+	})
+	if err != nil {
+		t.Fatalf("could not create s3: %v", err)
+	}
+
+	var dst bytes.Buffer
+	n, err := s3.DownloadStream(context.Background(), &dst, "s3Path")
+	if err != nil {
+		t.Fatalf("err expected:nil, actual:%v", err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("n expected:%v, actual:%v", len(content), n)
+	}
+	if dst.String() != string(content) {
+		t.Errorf("destination expected to contain content exactly once:%q, actual:%q", content, dst.String())
+	}
+
+	if downloader.ExpectedPosition != downloader.CurrentPosition {
+		t.Errorf("downloader counter expected:%v, actual:%v", downloader.ExpectedPosition, downloader.CurrentPosition)
+	}
+	if headGetter.ExpectedPosition != headGetter.CurrentPosition {
+		t.Errorf("headGetter counter expected:%v, actual:%v", headGetter.ExpectedPosition, headGetter.CurrentPosition)
+	}
+	if hashMaker.ExpectedPosition != hashMaker.CurrentPosition {
+		t.Errorf("hashMaker counter expected:%v, actual:%v", hashMaker.ExpectedPosition, hashMaker.CurrentPosition)
+	}
+}
 
 func TestGetMultiPartSize(t *testing.T) {
 	cases := []struct {
@@ -523,3 +1008,358 @@ func TestGetMultiPartSize(t *testing.T) {
 		}
 	}
 }
+
+func TestCandidateMultiPartSizes(t *testing.T) {
+	cases := []struct {
+		describe string
+		fileSize int
+		partCnt  int
+		want     []int
+	}{
+		{
+			"AWS SDKのデフォルトパートサイズで矛盾なく割り切れるとき、それが最初の候補になる",
+			16 * 1024 * 1024,
+			2,
+			[]int{8 * 1024 * 1024},
+		},
+		{
+			"GetMultiPartSizeの推測とは異なるパートサイズでアップロードされたときも候補に含める",
+			40 * 1024 * 1024,
+			3,
+			[]int{14 * 1024 * 1024, 16 * 1024 * 1024},
+		},
+		{
+			"パート数が1以下でGetMultiPartSizeがエラーになり、かつどのパートサイズとも一致しないとき候補は空になる",
+			0,
+			1,
+			nil,
+		},
+	}
+	for _, c := range cases {
+		got := candidateMultiPartSizes(c.fileSize, c.partCnt)
+
+		if len(got) != len(c.want) {
+			t.Errorf("%v len expected:%v, actual:%v", c.describe, c.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%v expected:%v, actual:%v", c.describe, c.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestCandidateMultiPartSizesNoDuplicates(t *testing.T) {
+	// commonPartSizesMBのいずれかがGetMultiPartSizeの推測と一致するとき、
+	// 同じパートサイズが候補に2回現れないことを確認する。
+	got := candidateMultiPartSizes(16*1024*1024, 2)
+
+	seen := make(map[int]bool, len(got))
+	for _, partSize := range got {
+		if seen[partSize] {
+			t.Errorf("candidateMultiPartSizes returned duplicate part size %v: %v", partSize, got)
+		}
+		seen[partSize] = true
+	}
+}
+
+// expectedMultiPartMD5 computes the composite MD5 HashMaker uses for a
+// multipart ETag over b, independently of the production code, so the test
+// below doesn't just restate makeMultiPartSerial/makeMultiPartConcurrent's
+// own implementation.
+func expectedMultiPartMD5(b []byte, partSize int) string {
+	var digests []byte
+	for i := 0; i < len(b); i += partSize {
+		end := i + partSize
+		if end > len(b) {
+			end = len(b)
+		}
+		sum := md5.Sum(b[i:end]) //nolint:gosec
+		digests = append(digests, sum[:]...)
+	}
+	sum := md5.Sum(digests) //nolint:gosec
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashMakerMultiPartSerialAndConcurrentAgree(t *testing.T) {
+	const partSize = 10
+
+	cases := []struct {
+		describe string
+		data     []byte
+	}{
+		{"パートサイズに割り切れないデータ", bytes.Repeat([]byte("0123456789"), 3)[:25]},
+		{"パートサイズにちょうど割り切れるデータ", bytes.Repeat([]byte("0123456789"), 3)},
+		{"空データ", []byte{}},
+	}
+
+	for _, c := range cases {
+		tmp, err := os.CreateTemp(t.TempDir(), "hashmaker-test-*")
+		if err != nil {
+			t.Fatalf("%v: could not create temp file: %v", c.describe, err)
+		}
+		if _, err := tmp.Write(c.data); err != nil {
+			t.Fatalf("%v: could not write temp file: %v", c.describe, err)
+		}
+
+		want := expectedMultiPartMD5(c.data, partSize)
+
+		serialHashMaker := &HashMaker{}
+		serialHash, err := serialHashMaker.makeMultiPartSerial(tmp, partSize)
+		if err != nil {
+			t.Errorf("%v: makeMultiPartSerial err expected:nil, actual:%v", c.describe, err)
+		} else if serialHash != want {
+			t.Errorf("%v: makeMultiPartSerial expected:%v, actual:%v", c.describe, want, serialHash)
+		}
+
+		concurrentHashMaker := &HashMaker{Concurrency: 4}
+		concurrentHash, err := concurrentHashMaker.makeMultiPart(tmp, partSize)
+		if err != nil {
+			t.Errorf("%v: makeMultiPart(concurrent) err expected:nil, actual:%v", c.describe, err)
+		} else if concurrentHash != want {
+			t.Errorf("%v: makeMultiPart(concurrent) expected:%v, actual:%v", c.describe, want, concurrentHash)
+		}
+
+		tmp.Close()
+	}
+}
+
+// rawChecksumDigest computes the raw (non-base64) digest ChecksumVerifier
+// uses for algorithm over b, independently of the production code, so the
+// tests below don't just restate ChecksumVerifier's own implementation.
+func rawChecksumDigest(algorithm string, b []byte) []byte {
+	switch algorithm {
+	case "CRC32C":
+		sum := crc32.Checksum(b, crc32.MakeTable(crc32.Castagnoli))
+		buf := make([]byte, 4)
+		buf[0] = byte(sum >> 24)
+		buf[1] = byte(sum >> 16)
+		buf[2] = byte(sum >> 8)
+		buf[3] = byte(sum)
+		return buf
+	case "SHA256":
+		sum := sha256.Sum256(b)
+		return sum[:]
+	}
+	return nil
+}
+
+func expectedSinglePartChecksum(algorithm string, b []byte) string {
+	return base64.StdEncoding.EncodeToString(rawChecksumDigest(algorithm, b))
+}
+
+func expectedMultiPartChecksum(algorithm string, b []byte, partSize int) string {
+	var digests []byte
+	for i := 0; i < len(b); i += partSize {
+		end := i + partSize
+		if end > len(b) {
+			end = len(b)
+		}
+		digests = append(digests, rawChecksumDigest(algorithm, b[i:end])...)
+	}
+	return expectedSinglePartChecksum(algorithm, digests)
+}
+
+func TestChecksumVerifierMakeSinglePart(t *testing.T) {
+	cases := []struct {
+		describe  string
+		algorithm string
+		data      []byte
+	}{
+		{"CRC32Cで単一パートのチェックサムを計算する", "CRC32C", []byte("hello world")},
+		{"SHA256で単一パートのチェックサムを計算する", "SHA256", []byte("hello world")},
+		{"空データでもチェックサムを計算できる", "SHA256", []byte("")},
+	}
+	for _, c := range cases {
+		cv := &ChecksumVerifier{Algorithm: c.algorithm}
+		checksum, err := cv.makeSinglePart(bytes.NewReader(c.data))
+		if err != nil {
+			t.Errorf("%v err expected:nil, actual:%v", c.describe, err)
+			continue
+		}
+		want := expectedSinglePartChecksum(c.algorithm, c.data)
+		if checksum != want {
+			t.Errorf("%v checksum expected:%v, actual:%v", c.describe, want, checksum)
+		}
+	}
+}
+
+func TestChecksumVerifierMakeMultiPart(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 3) // 30 bytes, 3 parts of 10
+
+	cases := []struct {
+		describe  string
+		algorithm string
+		partSize  int
+	}{
+		{"CRC32Cでパートサイズに割り切れるマルチパートのチェックサムを計算する", "CRC32C", 10},
+		{"SHA256でパートサイズに割り切れるマルチパートのチェックサムを計算する", "SHA256", 10},
+		{"最後のパートが端数になるときも正しく計算する", "SHA256", 8},
+	}
+	for _, c := range cases {
+		cv := &ChecksumVerifier{Algorithm: c.algorithm}
+		checksum, err := cv.makeMultiPart(bytes.NewReader(data), c.partSize)
+		if err != nil {
+			t.Errorf("%v err expected:nil, actual:%v", c.describe, err)
+			continue
+		}
+		want := expectedMultiPartChecksum(c.algorithm, data, c.partSize)
+		if checksum != want {
+			t.Errorf("%v checksum expected:%v, actual:%v", c.describe, want, checksum)
+		}
+	}
+}
+
+func TestChecksumVerifierUnsupportedAlgorithm(t *testing.T) {
+	cv := &ChecksumVerifier{Algorithm: "SHA1"}
+
+	if _, err := cv.makeSinglePart(bytes.NewReader([]byte("data"))); err == nil {
+		t.Error("makeSinglePart err expected:non-nil, actual:nil")
+	}
+	if _, err := cv.makeMultiPart(bytes.NewReader([]byte("data")), 10); err == nil {
+		t.Error("makeMultiPart err expected:non-nil, actual:nil")
+	}
+}
+
+func TestExponentialBackoffDelay(t *testing.T) {
+	cases := []struct {
+		describe string
+		backoff  ExponentialBackoff
+		attempt  int
+		maxWant  time.Duration
+	}{
+		{
+			"0回目は基準値（Base）を超えない",
+			ExponentialBackoff{Base: 100 * time.Millisecond, Max: 20 * time.Second, Multiplier: 2.0},
+			0,
+			100 * time.Millisecond,
+		},
+		{
+			"3回目はBase*Multiplier^3を超えない",
+			ExponentialBackoff{Base: 100 * time.Millisecond, Max: 20 * time.Second, Multiplier: 2.0},
+			3,
+			800 * time.Millisecond,
+		},
+		{
+			"試行回数が大きくてもMaxを超えない",
+			ExponentialBackoff{Base: 100 * time.Millisecond, Max: 20 * time.Second, Multiplier: 2.0},
+			100,
+			20 * time.Second,
+		},
+		{
+			"ゼロ値のフィールドはデフォルトにフォールバックする",
+			ExponentialBackoff{},
+			0,
+			defaultBackoffBase,
+		},
+	}
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			got := c.backoff.delay(c.attempt)
+			if got < 0 {
+				t.Errorf("%v delay expected:>=0, actual:%v", c.describe, got)
+			}
+			if got > c.maxWant {
+				t.Errorf("%v delay expected:<=%v, actual:%v", c.describe, c.maxWant, got)
+			}
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	apiErr := func(code string) error {
+		return &smithy.GenericAPIError{Code: code, Message: "boom"}
+	}
+
+	cases := []struct {
+		describe string
+		err      error
+		want     bool
+	}{
+		{"エラーがnilのときリトライしない", nil, false},
+		{"キャンセルされたコンテキストのエラーはリトライしない", context.Canceled, false},
+		{"タイムアウトしたコンテキストのエラーはリトライしない", context.DeadlineExceeded, false},
+		{"NoSuchBucketはリトライしない", apiErr("NoSuchBucket"), false},
+		{"NoSuchKeyはリトライしない", apiErr("NoSuchKey"), false},
+		{"AccessDeniedはリトライしない", apiErr("AccessDenied"), false},
+		{"InvalidAccessKeyIdはリトライしない", apiErr("InvalidAccessKeyId"), false},
+		{"SignatureDoesNotMatchはリトライしない", apiErr("SignatureDoesNotMatch"), false},
+		{"それ以外のAPIエラーはリトライする", apiErr("InternalError"), true},
+		{"APIエラーでない一般的なエラーはリトライする", errors.New("connection reset"), true},
+	}
+	for _, c := range cases {
+		got := isRetryable(c.err)
+		if got != c.want {
+			t.Errorf("%v expected:%v, actual:%v", c.describe, c.want, got)
+		}
+	}
+}
+
+const benchmarkPartSize = 8 * megabyte
+
+func benchmarkHashMakerFile(b *testing.B, size int64) *os.File {
+	b.Helper()
+
+	tmp, err := os.CreateTemp("", "hashmaker-bench-*")
+	if err != nil {
+		b.Fatalf("could not create temp file: %v", err)
+	}
+	b.Cleanup(func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	})
+
+	if err := tmp.Truncate(size); err != nil {
+		b.Fatalf("could not size temp file: %v", err)
+	}
+	return tmp
+}
+
+// benchmarkHashMakerMultiPartSerial calls makeMultiPartSerial directly rather
+// than going through makeMultiPart, which would dispatch to
+// makeMultiPartConcurrent instead: *os.File implements io.ReaderAt, so
+// makeMultiPart never takes the serial path for file-backed input regardless
+// of Concurrency.
+func benchmarkHashMakerMultiPartSerial(b *testing.B, size int64) {
+	tmp := benchmarkHashMakerFile(b, size)
+	hashMaker := &HashMaker{}
+
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := hashMaker.makeMultiPartSerial(tmp, benchmarkPartSize); err != nil {
+			b.Fatalf("makeMultiPartSerial: %v", err)
+		}
+	}
+}
+
+func benchmarkHashMakerMultiPartConcurrent(b *testing.B, size int64, concurrency int) {
+	tmp := benchmarkHashMakerFile(b, size)
+	hashMaker := &HashMaker{Concurrency: concurrency}
+
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := hashMaker.makeMultiPart(tmp, benchmarkPartSize); err != nil {
+			b.Fatalf("makeMultiPart: %v", err)
+		}
+	}
+}
+
+func BenchmarkHashMakerMultiPartSerial1GiB(b *testing.B) {
+	benchmarkHashMakerMultiPartSerial(b, 1<<30)
+}
+
+func BenchmarkHashMakerMultiPartConcurrent1GiB(b *testing.B) {
+	benchmarkHashMakerMultiPartConcurrent(b, 1<<30, runtime.NumCPU())
+}
+
+func BenchmarkHashMakerMultiPartSerial8GiB(b *testing.B) {
+	benchmarkHashMakerMultiPartSerial(b, 8<<30)
+}
+
+func BenchmarkHashMakerMultiPartConcurrent8GiB(b *testing.B) {
+	benchmarkHashMakerMultiPartConcurrent(b, 8<<30, runtime.NumCPU())
+}